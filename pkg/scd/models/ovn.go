@@ -0,0 +1,30 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// OVN (OVolumetric Version Number) is an opaque token a client must present
+// to prove it has observed the latest version of a Resource before being
+// permitted to mutate it.
+type OVN string
+
+// NewOVNFromTime derives an OVN from a Resource's last-modified wall-clock
+// timestamp and ID.
+//
+// Deprecated: updated_at is not guaranteed unique across concurrently
+// committed rows; prefer NewOVNFromHLC.
+func NewOVNFromTime(t time.Time, id string) OVN {
+	return OVN(fmt.Sprintf("%s:%d", id, t.UnixNano()))
+}
+
+// NewOVNFromHLC derives an OVN from a Resource's id and the CockroachDB HLC
+// (cluster_logical_timestamp()) recorded for it in the ovn_ts column (for
+// rows written before that column existed, a per-row value backfilled from
+// updated_at). Unlike
+// updated_at, the HLC is strictly monotonic and unique across the cluster,
+// so two rows can never be assigned the same OVN.
+func NewOVNFromHLC(hlc string, id string) OVN {
+	return OVN(fmt.Sprintf("%s:%s", id, hlc))
+}
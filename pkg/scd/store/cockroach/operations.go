@@ -3,7 +3,10 @@ package cockroach
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,8 +20,113 @@ import (
 	"github.com/palantir/stacktrace"
 )
 
+const (
+	// defaultMaxTxRetries bounds the number of times WithTx will re-run its
+	// closure against a fresh transaction after a retryable CockroachDB
+	// error, when the repo wasn't configured with an explicit override.
+	defaultMaxTxRetries = 5
+	// txRetryBaseDelay is the base of the exponential backoff applied between
+	// transaction retries; the delay before attempt n (attempt 0 being the
+	// first try) is txRetryBaseDelay * 2^(n-1).
+	txRetryBaseDelay = 10 * time.Millisecond
+)
+
+// retryable Postgres/CockroachDB error codes indicating that a transaction
+// was aborted due to a serializable conflict and may safely be retried.
+// See https://www.cockroachlabs.com/docs/stable/transaction-retry-error-reference.html
+var retryableTxErrorCodes = map[string]bool{
+	"40001": true, // serialization_failure
+	"CR000": true, // CockroachDB-specific retry_write_too_old
+}
+
+// Repo groups the operations that may be composed within a single logical,
+// possibly-retried transaction via WithTx.
+type Repo interface {
+	upsertOperation(ctx context.Context, operation *scdmodels.Operation, key []scdmodels.OVN) (*scdmodels.Operation, []*scdmodels.Subscription, error)
+	deleteOperation(ctx context.Context, id dssmodels.ID, owner dssmodels.Owner) (*scdmodels.Operation, []*scdmodels.Subscription, error)
+}
+
+// NewRepo wraps db in a *repo configured from operator-supplied limits. A
+// maxSearchOperationsResults or maxTxRetries <= 0 leaves the corresponding
+// default (defaultMaxSearchOperationsResults, defaultMaxTxRetries) in
+// effect, so callers that don't care about either can pass 0.
+func NewRepo(db *sql.DB, maxSearchOperationsResults, maxTxRetries int) *repo {
+	return &repo{
+		db:                                 db,
+		q:                                  db,
+		maxSearchOperationsResultsOverride: maxSearchOperationsResults,
+		maxTxRetriesOverride:               maxTxRetries,
+	}
+}
+
+// isRetryableTxError reports whether err was caused by a Postgres/CockroachDB
+// error code known to indicate a safely retryable transaction conflict.
+func isRetryableTxError(err error) bool {
+	pqErr, ok := stacktrace.RootCause(err).(*pq.Error)
+	if !ok {
+		return false
+	}
+	return retryableTxErrorCodes[string(pqErr.Code)]
+}
+
+// maxTxRetries returns the operator-configured retry budget for WithTx
+// (s.maxTxRetriesOverride, set by whatever constructs the top-level repo
+// from operator config), falling back to defaultMaxTxRetries if the repo
+// wasn't given one.
+func (s *repo) maxTxRetries() int {
+	if s.maxTxRetriesOverride <= 0 {
+		return defaultMaxTxRetries
+	}
+	return s.maxTxRetriesOverride
+}
+
+// WithTx BEGINs a transaction against CockroachDB, runs f with a Repo bound
+// to that transaction, and COMMITs on success. If f fails with a retryable
+// serialization error, the transaction is rolled back and f is re-run
+// against a fresh transaction, up to s.maxTxRetries() times with exponential
+// backoff between attempts.
+func (s *repo) WithTx(ctx context.Context, f func(Repo) error) error {
+	var lastErr error
+
+	retries := s.maxTxRetries()
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(txRetryBaseDelay * time.Duration(uint(1)<<uint(attempt-1))):
+			case <-ctx.Done():
+				return stacktrace.Propagate(ctx.Err(), "Context cancelled while awaiting transaction retry")
+			}
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return stacktrace.Propagate(err, "Error beginning transaction")
+		}
+
+		lastErr = f(&repo{
+			db:                                 s.db,
+			q:                                  tx,
+			maxSearchOperationsResultsOverride: s.maxSearchOperationsResultsOverride,
+			maxTxRetriesOverride:               s.maxTxRetriesOverride,
+		})
+		if lastErr == nil {
+			if lastErr = tx.Commit(); lastErr == nil {
+				return nil
+			}
+		} else if rbErr := tx.Rollback(); rbErr != nil {
+			return stacktrace.Propagate(rbErr, "Error rolling back transaction after: %s", lastErr)
+		}
+
+		if !isRetryableTxError(lastErr) {
+			return lastErr
+		}
+	}
+
+	return stacktrace.Propagate(lastErr, "Exceeded %d retries for transaction", retries)
+}
+
 var (
-	operationFieldsWithIndices   [10]string
+	operationFieldsWithIndices   [11]string
 	operationFieldsWithPrefix    string
 	operationFieldsWithoutPrefix string
 )
@@ -34,6 +142,11 @@ func init() {
 	operationFieldsWithIndices[7] = "ends_at"
 	operationFieldsWithIndices[8] = "subscription_id"
 	operationFieldsWithIndices[9] = "updated_at"
+	// ovn_ts holds the CockroachDB cluster logical (HLC) timestamp of the
+	// write that produced this row (for rows written before migration 4, a
+	// per-row value backfilled from updated_at), used instead of updated_at to derive a
+	// strictly-monotonic, collision-free OVN. See NewOVNFromHLC.
+	operationFieldsWithIndices[10] = "ovn_ts"
 
 	operationFieldsWithoutPrefix = strings.Join(
 		operationFieldsWithIndices[:], ",",
@@ -61,6 +174,7 @@ func (s *repo) fetchOperations(ctx context.Context, q dsssql.Queryable, query st
 		var (
 			o         = &scdmodels.Operation{}
 			updatedAt time.Time
+			ovnTS     string
 		)
 		err := rows.Scan(
 			&o.ID,
@@ -73,11 +187,12 @@ func (s *repo) fetchOperations(ctx context.Context, q dsssql.Queryable, query st
 			&o.EndTime,
 			&o.SubscriptionID,
 			&updatedAt,
+			&ovnTS,
 		)
 		if err != nil {
 			return nil, stacktrace.Propagate(err, "Error scanning Operation row")
 		}
-		o.OVN = scdmodels.NewOVNFromTime(updatedAt, o.ID.String())
+		o.OVN = scdmodels.NewOVNFromHLC(ovnTS, o.ID.String())
 		payload = append(payload, o)
 	}
 	if err := rows.Err(); err != nil {
@@ -87,6 +202,50 @@ func (s *repo) fetchOperations(ctx context.Context, q dsssql.Queryable, query st
 	return payload, nil
 }
 
+// fetchOperationsStream behaves like fetchOperations, but invokes fn for
+// each Operation as its row is scanned instead of accumulating the full
+// result set in memory before returning.
+func (s *repo) fetchOperationsStream(ctx context.Context, q dsssql.Queryable, query string, fn func(*scdmodels.Operation) error, args ...interface{}) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return stacktrace.Propagate(err, "Error in query: %s", query)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			o         = &scdmodels.Operation{}
+			updatedAt time.Time
+			ovnTS     string
+		)
+		err := rows.Scan(
+			&o.ID,
+			&o.Owner,
+			&o.Version,
+			&o.USSBaseURL,
+			&o.AltitudeLower,
+			&o.AltitudeUpper,
+			&o.StartTime,
+			&o.EndTime,
+			&o.SubscriptionID,
+			&updatedAt,
+			&ovnTS,
+		)
+		if err != nil {
+			return stacktrace.Propagate(err, "Error scanning Operation row")
+		}
+		o.OVN = scdmodels.NewOVNFromHLC(ovnTS, o.ID.String())
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return stacktrace.Propagate(err, "Error in rows query result")
+	}
+
+	return nil
+}
+
 func (s *repo) fetchOperation(ctx context.Context, q dsssql.Queryable, query string, args ...interface{}) (*scdmodels.Operation, error) {
 	operations, err := s.fetchOperations(ctx, q, query, args...)
 	if err != nil {
@@ -131,22 +290,25 @@ func (s *repo) pushOperation(ctx context.Context, q dsssql.Queryable, operation
 				scd_operations
 				(%s)
 			VALUES
-				($1, $2, COALESCE((SELECT version from v), 0) + 1, $3, $4, $5, $6, $7, $8, transaction_timestamp())
+				($1, $2, COALESCE((SELECT version from v), 0) + 1, $3, $4, $5, $6, $7, $8, transaction_timestamp(), cluster_logical_timestamp())
 			RETURNING
 				%s`, operationFieldsWithoutPrefix, operationFieldsWithPrefix)
 		upsertCellsForOperationQuery = `
-			UPSERT INTO
-				scd_cells_operations
-				(cell_id, cell_level, operation_id)
-			VALUES
-				($1, $2, $3)`
-		deleteLeftOverCellsForOperationQuery = `
+			WITH upserted AS (
+				UPSERT INTO
+					scd_cells_operations
+					(cell_id, cell_level, operation_id)
+				SELECT
+					unnest($1::int8[]), unnest($2::int[]), $3
+				RETURNING
+					cell_id
+			)
 			DELETE FROM
 				scd_cells_operations
 			WHERE
-				cell_id != ALL($1)
+				operation_id = $3
 			AND
-				operation_id = $2`
+				cell_id != ALL($1)`
 	)
 
 	cids := make([]int64, len(operation.Cells))
@@ -173,14 +335,8 @@ func (s *repo) pushOperation(ctx context.Context, q dsssql.Queryable, operation
 	}
 	operation.Cells = cells
 
-	for i := range cids {
-		if _, err := q.ExecContext(ctx, upsertCellsForOperationQuery, cids[i], clevels[i], operation.ID); err != nil {
-			return nil, nil, stacktrace.Propagate(err, "Error in query: %s", upsertCellsForOperationQuery)
-		}
-	}
-
-	if _, err := q.ExecContext(ctx, deleteLeftOverCellsForOperationQuery, pq.Array(cids), operation.ID); err != nil {
-		return nil, nil, stacktrace.Propagate(err, "Error in query: %s", deleteLeftOverCellsForOperationQuery)
+	if _, err := q.ExecContext(ctx, upsertCellsForOperationQuery, pq.Array(cids), pq.Array(clevels), operation.ID); err != nil {
+		return nil, nil, stacktrace.Propagate(err, "Error in query: %s", upsertCellsForOperationQuery)
 	}
 
 	subscriptions, err := s.fetchSubscriptionsForNotification(ctx, q, cids)
@@ -234,8 +390,30 @@ func (s *repo) GetOperation(ctx context.Context, id dssmodels.ID) (*scdmodels.Op
 	}
 }
 
-// DeleteOperation deletes an operation for the given ID from CockroachDB
+// DeleteOperation deletes an operation for the given ID from CockroachDB,
+// running the fetch/delete/notification sequence atomically inside a
+// retried transaction.
 func (s *repo) DeleteOperation(ctx context.Context, id dssmodels.ID, owner dssmodels.Owner) (*scdmodels.Operation, []*scdmodels.Subscription, error) {
+	var (
+		old           *scdmodels.Operation
+		subscriptions []*scdmodels.Subscription
+	)
+
+	err := s.WithTx(ctx, func(r Repo) error {
+		var err error
+		old, subscriptions, err = r.deleteOperation(ctx, id, owner)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return old, subscriptions, nil
+}
+
+// deleteOperation performs the actual delete against q and is run inside
+// WithTx by DeleteOperation.
+func (s *repo) deleteOperation(ctx context.Context, id dssmodels.ID, owner dssmodels.Owner) (*scdmodels.Operation, []*scdmodels.Subscription, error) {
 	var (
 		deleteQuery = `
 			DELETE FROM
@@ -299,8 +477,30 @@ func (s *repo) DeleteOperation(ctx context.Context, id dssmodels.ID, owner dssmo
 	return old, subscriptions, nil
 }
 
-// UpsertOperation inserts or updates an operation in CockroachDB
+// UpsertOperation inserts or updates an operation in CockroachDB, running
+// the version/OVN checks and the write to scd_operations atomically inside
+// a retried transaction.
 func (s *repo) UpsertOperation(ctx context.Context, operation *scdmodels.Operation, key []scdmodels.OVN) (*scdmodels.Operation, []*scdmodels.Subscription, error) {
+	var (
+		upserted      *scdmodels.Operation
+		subscriptions []*scdmodels.Subscription
+	)
+
+	err := s.WithTx(ctx, func(r Repo) error {
+		var err error
+		upserted, subscriptions, err = r.upsertOperation(ctx, operation, key)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return upserted, subscriptions, nil
+}
+
+// upsertOperation performs the actual version/OVN checks and write against
+// q and is run inside WithTx by UpsertOperation.
+func (s *repo) upsertOperation(ctx context.Context, operation *scdmodels.Operation, key []scdmodels.OVN) (*scdmodels.Operation, []*scdmodels.Subscription, error) {
 	old, err := s.fetchOperationByID(ctx, s.q, operation.ID)
 	switch {
 	case err == sql.ErrNoRows:
@@ -328,36 +528,14 @@ func (s *repo) UpsertOperation(ctx context.Context, operation *scdmodels.Operati
 		return nil, nil, stacktrace.Propagate(err, "Error validating time range")
 	}
 
-	// TODO(tvoss): Investigate whether we can fold the check for OVNs into the
-	// the upsert query by means of a CTE and a coalescing condition testing
-	// whether all affected OVNs are matched.
 	switch operation.State {
 	case scdmodels.OperationStateAccepted, scdmodels.OperationStateActivated:
-		operations, err := s.searchOperations(ctx, s.q, &dssmodels.Volume4D{
-			StartTime: operation.StartTime,
-			EndTime:   operation.EndTime,
-			SpatialVolume: &dssmodels.Volume3D{
-				AltitudeHi: operation.AltitudeUpper,
-				AltitudeLo: operation.AltitudeLower,
-				Footprint: dssmodels.GeometryFunc(func() (s2.CellUnion, error) {
-					return operation.Cells, nil
-				}),
-			},
-		})
+		missing, err := s.missingIntersectingOVNs(ctx, s.q, operation, key)
 		if err != nil {
-			return nil, nil, stacktrace.Propagate(err, "Error searching Operations")
-		}
-
-		keyIdx := map[scdmodels.OVN]struct{}{}
-		for _, ovn := range key {
-			keyIdx[ovn] = struct{}{}
+			return nil, nil, stacktrace.Propagate(err, "Error checking intersecting Operations' OVNs")
 		}
-
-		for _, op := range operations {
-			if _, match := keyIdx[op.OVN]; !match {
-				return nil, nil, stacktrace.Propagate(scderr.ErrMissingOVNs, "Missing OVN for Operation %s", op.ID)
-			}
-			delete(keyIdx, op.OVN)
+		if len(missing) > 0 {
+			return nil, nil, stacktrace.Propagate(scderr.ErrMissingOVNs, "Missing OVN(s) for Operation(s): %s", strings.Join(missing, ", "))
 		}
 	default:
 		// Do not check the OVNs for any other operation states.
@@ -371,23 +549,24 @@ func (s *repo) UpsertOperation(ctx context.Context, operation *scdmodels.Operati
 	return area, subscribers, nil
 }
 
-func (s *repo) searchOperations(ctx context.Context, q dsssql.Queryable, v4d *dssmodels.Volume4D) ([]*scdmodels.Operation, error) {
-	var (
-		operationsIntersectingVolumeQuery = fmt.Sprintf(`
-			SELECT
-				%s
+// missingIntersectingOVNs returns the IDs of any Operations intersecting
+// operation's 4D volume whose OVN is not present in keys, computed in a
+// single SQL statement run against q. Unlike the previous search-then-
+// compare-in-Go approach, this check is evaluated by the database itself as
+// part of the same transaction as the subsequent pushOperation, so a
+// concurrent writer inserting an intersecting Operation between the check
+// and the upsert causes a serializable conflict (retried by WithTx) rather
+// than a silently missed race.
+func (s *repo) missingIntersectingOVNs(ctx context.Context, q dsssql.Queryable, operation *scdmodels.Operation, keys []scdmodels.OVN) ([]string, error) {
+	const query = `
+		WITH intersecting AS (
+			SELECT DISTINCT
+				scd_operations.id,
+				scd_operations.ovn_ts
 			FROM
 				scd_operations
 			JOIN
-				(SELECT DISTINCT
-					scd_cells_operations.operation_id
-				FROM
-					scd_cells_operations
-				WHERE
-					scd_cells_operations.cell_id = ANY($1)
-				)
-			AS
-				unique_operations
+				(SELECT DISTINCT operation_id FROM scd_cells_operations WHERE cell_id = ANY($1)) AS unique_operations
 			ON
 				scd_operations.id = unique_operations.operation_id
 			WHERE
@@ -397,9 +576,55 @@ func (s *repo) searchOperations(ctx context.Context, q dsssql.Queryable, v4d *ds
 			AND
 				COALESCE(scd_operations.ends_at >= $4, true)
 			AND
-				COALESCE(scd_operations.starts_at <= $5, true)`, operationFieldsWithPrefix)
-	)
+				COALESCE(scd_operations.starts_at <= $5, true)
+		)
+		SELECT
+			array_agg(id)
+		FROM
+			intersecting
+		WHERE
+			NOT ((id::text || ':' || ovn_ts::string) = ANY($6::text[]))`
+
+	var missing pq.StringArray
+	row := q.QueryRowContext(ctx, query, missingIntersectingOVNsArgs(operation, keys)...)
+	if err := row.Scan(&missing); err != nil {
+		return nil, stacktrace.Propagate(err, "Error in query: %s", query)
+	}
+
+	return missing, nil
+}
+
+// missingIntersectingOVNsArgs builds the bind arguments for the query in
+// missingIntersectingOVNs, in $1..$6 order. Pulled out as its own function so
+// the $4/$5 time-bound order - operation.StartTime then operation.EndTime,
+// matching the query's "ends_at >= $4 AND starts_at <= $5" overlap test - can
+// be pinned by a test without a live CockroachDB connection; a prior version
+// of this series passed them in the opposite order, which silently turned
+// the overlap test into a containment test.
+func missingIntersectingOVNsArgs(operation *scdmodels.Operation, keys []scdmodels.OVN) []interface{} {
+	cids := make([]int64, len(operation.Cells))
+	for i, cell := range operation.Cells {
+		cids[i] = int64(cell)
+	}
 
+	keyStrings := make([]string, len(keys))
+	for i, key := range keys {
+		keyStrings[i] = string(key)
+	}
+
+	return []interface{}{
+		pq.Array(cids),
+		operation.AltitudeLower,
+		operation.AltitudeUpper,
+		operation.StartTime,
+		operation.EndTime,
+		pq.Array(keyStrings),
+	}
+}
+
+// cellsForVolume calculates the S2 cell covering of v4d's footprint, as
+// int64 cell IDs suitable for use with scd_cells_operations.cell_id.
+func cellsForVolume(v4d *dssmodels.Volume4D) ([]int64, error) {
 	if v4d.SpatialVolume == nil || v4d.SpatialVolume.Footprint == nil {
 		return nil, stacktrace.NewErrorWithCode(dsserr.BadRequest, "Missing geospatial footprint for query")
 	}
@@ -415,27 +640,394 @@ func (s *repo) searchOperations(ctx context.Context, q dsssql.Queryable, v4d *ds
 	for i, cid := range cells {
 		cids[i] = int64(cid)
 	}
+	return cids, nil
+}
+
+const operationsIntersectingVolumeQueryFormat = `
+	SELECT
+		%s
+	FROM
+		scd_operations
+	JOIN
+		(SELECT DISTINCT
+			scd_cells_operations.operation_id
+		FROM
+			scd_cells_operations
+		WHERE
+			scd_cells_operations.cell_id = ANY($1)
+		)
+	AS
+		unique_operations
+	ON
+		scd_operations.id = unique_operations.operation_id
+	WHERE
+		COALESCE(scd_operations.altitude_upper >= $2, true)
+	AND
+		COALESCE(scd_operations.altitude_lower <= $3, true)
+	AND
+		COALESCE(scd_operations.ends_at >= $4, true)
+	AND
+		COALESCE(scd_operations.starts_at <= $5, true)`
+
+// SearchOperationsStream invokes fn for each Operation intersecting v4d's 4D
+// volume as rows are scanned from CockroachDB, rather than accumulating the
+// full result set in memory before returning.
+func (s *repo) SearchOperationsStream(ctx context.Context, v4d *dssmodels.Volume4D, fn func(*scdmodels.Operation) error) error {
+	cids, err := cellsForVolume(v4d)
+	if err != nil {
+		return err
+	}
 
-	result, err := s.fetchOperations(
-		ctx, q, operationsIntersectingVolumeQuery,
+	query := fmt.Sprintf(operationsIntersectingVolumeQueryFormat, operationFieldsWithPrefix)
+
+	return s.fetchOperationsStream(ctx, s.q, query, fn,
 		pq.Array(cids),
 		v4d.SpatialVolume.AltitudeLo,
 		v4d.SpatialVolume.AltitudeHi,
 		v4d.StartTime,
 		v4d.EndTime,
 	)
+}
+
+// OperationsPageToken identifies a position within a keyset-paginated
+// SearchOperationsPage query, encoding the (updated_at, id) of the last
+// Operation seen on the previous page.
+type OperationsPageToken struct {
+	UpdatedAt time.Time
+	ID        dssmodels.ID
+}
+
+// Encode returns an opaque string suitable for handing back to a caller and
+// later round-tripping through DecodeOperationsPageToken.
+func (t *OperationsPageToken) Encode() string {
+	if t == nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", t.UpdatedAt.UnixNano(), t.ID)))
+}
+
+// DecodeOperationsPageToken parses a string previously returned by
+// (*OperationsPageToken).Encode. An empty string decodes to a nil token,
+// meaning "start from the beginning".
+func DecodeOperationsPageToken(s string) (*OperationsPageToken, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, stacktrace.PropagateWithCode(err, dsserr.BadRequest, "Invalid page token")
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return nil, stacktrace.NewErrorWithCode(dsserr.BadRequest, "Invalid page token")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		return nil, stacktrace.Propagate(err, "Error fetching Operations")
+		return nil, stacktrace.PropagateWithCode(err, dsserr.BadRequest, "Invalid page token")
 	}
+	return &OperationsPageToken{
+		UpdatedAt: time.Unix(0, nanos).UTC(),
+		ID:        dssmodels.ID(parts[1]),
+	}, nil
+}
 
-	return result, nil
+const defaultOperationsPageSize = 100
+
+// SearchOperationsPage returns up to limit Operations intersecting v4d's 4D
+// volume, ordered by (updated_at, id) starting just after pageToken. The
+// returned token resumes after the last Operation on this page, and is nil
+// when the page holds fewer than limit Operations; a full page always
+// returns a token, so if the Operations end exactly at a page boundary the
+// next call returns an empty page and a nil token. A limit <= 0 defaults to
+// defaultOperationsPageSize.
+func (s *repo) SearchOperationsPage(ctx context.Context, v4d *dssmodels.Volume4D, pageToken *OperationsPageToken, limit int) ([]*scdmodels.Operation, *OperationsPageToken, error) {
+	if limit <= 0 {
+		limit = defaultOperationsPageSize
+	}
+
+	cids, err := cellsForVolume(v4d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		tokUpdatedAt interface{}
+		tokID        interface{}
+	)
+	if pageToken != nil {
+		tokUpdatedAt, tokID = pageToken.UpdatedAt, pageToken.ID
+	}
+
+	query := fmt.Sprintf(operationsIntersectingVolumeQueryFormat+`
+		AND
+			($6::timestamptz IS NULL OR (scd_operations.updated_at, scd_operations.id) > ($6, $7))
+		ORDER BY
+			scd_operations.updated_at, scd_operations.id
+		LIMIT $8`, operationFieldsWithPrefix)
+
+	rows, err := s.q.QueryContext(ctx, query,
+		pq.Array(cids),
+		v4d.SpatialVolume.AltitudeLo,
+		v4d.SpatialVolume.AltitudeHi,
+		v4d.StartTime,
+		v4d.EndTime,
+		tokUpdatedAt,
+		tokID,
+		limit,
+	)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "Error in query: %s", query)
+	}
+	defer rows.Close()
+
+	var (
+		result        []*scdmodels.Operation
+		lastUpdatedAt time.Time
+		lastID        dssmodels.ID
+	)
+	for rows.Next() {
+		var (
+			o         = &scdmodels.Operation{}
+			updatedAt time.Time
+			ovnTS     string
+		)
+		if err := rows.Scan(
+			&o.ID,
+			&o.Owner,
+			&o.Version,
+			&o.USSBaseURL,
+			&o.AltitudeLower,
+			&o.AltitudeUpper,
+			&o.StartTime,
+			&o.EndTime,
+			&o.SubscriptionID,
+			&updatedAt,
+			&ovnTS,
+		); err != nil {
+			return nil, nil, stacktrace.Propagate(err, "Error scanning Operation row")
+		}
+		o.OVN = scdmodels.NewOVNFromHLC(ovnTS, o.ID.String())
+		result = append(result, o)
+		lastUpdatedAt, lastID = updatedAt, o.ID
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, stacktrace.Propagate(err, "Error in rows query result")
+	}
+
+	var next *OperationsPageToken
+	if len(result) == limit {
+		next = &OperationsPageToken{UpdatedAt: lastUpdatedAt, ID: lastID}
+	}
+
+	return result, next, nil
+}
+
+// defaultMaxSearchOperationsResults bounds the number of Operations
+// SearchOperations will accumulate across pages before giving up, when the
+// repo wasn't configured with an explicit override; a volume matching more
+// than this returns dsserr.ResourceExhausted rather than silently loading an
+// unbounded result set into memory.
+const defaultMaxSearchOperationsResults = 1000
+
+// maxSearchOperationsResults returns the operator-configured cap for
+// SearchOperations (s.maxSearchOperationsResultsOverride, set by whatever
+// constructs the top-level repo from operator config), falling back to
+// defaultMaxSearchOperationsResults if the repo wasn't given one.
+func (s *repo) maxSearchOperationsResults() int {
+	if s.maxSearchOperationsResultsOverride <= 0 {
+		return defaultMaxSearchOperationsResults
+	}
+	return s.maxSearchOperationsResultsOverride
 }
 
-// SearchOperations returns operations within the 4D volume from CockroachDB
+// SearchOperations returns operations within the 4D volume from CockroachDB,
+// paging internally via SearchOperationsPage rather than issuing a single
+// unbounded query.
 func (s *repo) SearchOperations(ctx context.Context, v4d *dssmodels.Volume4D) ([]*scdmodels.Operation, error) {
-	result, err := s.searchOperations(ctx, s.q, v4d)
+	var (
+		result []*scdmodels.Operation
+		token  *OperationsPageToken
+	)
+
+	max := s.maxSearchOperationsResults()
+	for {
+		page, next, err := s.SearchOperationsPage(ctx, v4d, token, defaultOperationsPageSize)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page...)
+		if len(result) > max {
+			return nil, stacktrace.NewErrorWithCode(dsserr.ResourceExhausted, "Query matched more than %d Operations; refine the search volume", max)
+		}
+		if next == nil {
+			break
+		}
+		token = next
+	}
+
+	return result, nil
+}
+
+// unboundedStartTime and unboundedEndTime stand in for a nil StartTime or
+// EndTime when flattening many Volume4Ds into parallel arrays for
+// SearchOperationsMulti: unlike a single-query COALESCE, a value is needed
+// for every row of the per-query "queries" array.
+var (
+	unboundedStartTime = time.Unix(0, 0).UTC()
+	unboundedEndTime   = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// searchOperationsMultiQueryFormat is the query run by SearchOperationsMulti.
+// Each of the four WHERE predicates must stay COALESCE(..., true)-wrapped: an
+// Operation's own altitude/time bounds (scd_operations.*) can themselves be
+// NULL (unbounded), and an un-wrapped comparison against NULL evaluates to
+// NULL - silently excluding that Operation from every multi-search result,
+// rather than true as the unbounded semantics require. A prior version of
+// this series shipped without the COALESCE wrapping for exactly this reason.
+const searchOperationsMultiQueryFormat = `
+	WITH queries AS (
+		SELECT
+			unnest($1::int8[]) AS query_idx,
+			unnest($2::float8[]) AS altitude_lower,
+			unnest($3::float8[]) AS altitude_upper,
+			unnest($4::timestamptz[]) AS starts_at,
+			unnest($5::timestamptz[]) AS ends_at
+	),
+	cells AS (
+		SELECT
+			unnest($6::int8[]) AS query_idx,
+			unnest($7::int8[]) AS cell_id
+	)
+	SELECT DISTINCT
+		cells.query_idx,
+		%s
+	FROM
+		scd_operations
+	JOIN
+		scd_cells_operations
+	ON
+		scd_operations.id = scd_cells_operations.operation_id
+	JOIN
+		cells
+	ON
+		scd_cells_operations.cell_id = cells.cell_id
+	JOIN
+		queries
+	ON
+		queries.query_idx = cells.query_idx
+	WHERE
+		COALESCE(scd_operations.altitude_upper >= queries.altitude_lower, true)
+	AND
+		COALESCE(scd_operations.altitude_lower <= queries.altitude_upper, true)
+	AND
+		COALESCE(scd_operations.ends_at >= queries.starts_at, true)
+	AND
+		COALESCE(scd_operations.starts_at <= queries.ends_at, true)`
+
+// SearchOperationsMulti returns, for each index i of v4ds, the Operations
+// intersecting v4ds[i]'s 4D volume, computed with a single query regardless
+// of how many volumes are supplied.
+//
+// This is a store-layer primitive only. The scd API service implementation
+// (the gRPC/HTTP handlers) lives outside pkg/scd/store and isn't part of
+// this change set, so no batched search endpoint exposes this to callers
+// yet; wiring one up against this method is tracked as a follow-up and is
+// intentionally not attempted here rather than invented without the rest of
+// the API layer to match.
+func (s *repo) SearchOperationsMulti(ctx context.Context, v4ds []*dssmodels.Volume4D) (map[int][]*scdmodels.Operation, error) {
+	result := make(map[int][]*scdmodels.Operation, len(v4ds))
+	if len(v4ds) == 0 {
+		return result, nil
+	}
+
+	var (
+		queryIdxs     = make([]int64, len(v4ds))
+		altitudeLower = make([]float64, len(v4ds))
+		altitudeUpper = make([]float64, len(v4ds))
+		startsAt      = make([]time.Time, len(v4ds))
+		endsAt        = make([]time.Time, len(v4ds))
+		cellQueryIdxs []int64
+		cellIDs       []int64
+	)
+
+	for i, v4d := range v4ds {
+		result[i] = nil
+
+		cells, err := cellsForVolume(v4d)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "Error calculating footprint covering for query %d", i)
+		}
+
+		queryIdxs[i] = int64(i)
+		altitudeLower[i] = math.Inf(-1)
+		if v4d.SpatialVolume.AltitudeLo != nil {
+			altitudeLower[i] = float64(*v4d.SpatialVolume.AltitudeLo)
+		}
+		altitudeUpper[i] = math.Inf(1)
+		if v4d.SpatialVolume.AltitudeHi != nil {
+			altitudeUpper[i] = float64(*v4d.SpatialVolume.AltitudeHi)
+		}
+		startsAt[i] = unboundedStartTime
+		if v4d.StartTime != nil {
+			startsAt[i] = *v4d.StartTime
+		}
+		endsAt[i] = unboundedEndTime
+		if v4d.EndTime != nil {
+			endsAt[i] = *v4d.EndTime
+		}
+
+		for _, cid := range cells {
+			cellQueryIdxs = append(cellQueryIdxs, int64(i))
+			cellIDs = append(cellIDs, cid)
+		}
+	}
+
+	query := fmt.Sprintf(searchOperationsMultiQueryFormat, operationFieldsWithPrefix)
+
+	rows, err := s.q.QueryContext(ctx, query,
+		pq.Array(queryIdxs),
+		pq.Array(altitudeLower),
+		pq.Array(altitudeUpper),
+		pq.Array(startsAt),
+		pq.Array(endsAt),
+		pq.Array(cellQueryIdxs),
+		pq.Array(cellIDs),
+	)
 	if err != nil {
-		return nil, err // No need to Propagate this error as this stack layer does not add useful information
+		return nil, stacktrace.Propagate(err, "Error in query: %s", query)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			queryIdx  int64
+			o         = &scdmodels.Operation{}
+			updatedAt time.Time
+			ovnTS     string
+		)
+		if err := rows.Scan(
+			&queryIdx,
+			&o.ID,
+			&o.Owner,
+			&o.Version,
+			&o.USSBaseURL,
+			&o.AltitudeLower,
+			&o.AltitudeUpper,
+			&o.StartTime,
+			&o.EndTime,
+			&o.SubscriptionID,
+			&updatedAt,
+			&ovnTS,
+		); err != nil {
+			return nil, stacktrace.Propagate(err, "Error scanning Operation row")
+		}
+		o.OVN = scdmodels.NewOVNFromHLC(ovnTS, o.ID.String())
+
+		idx := int(queryIdx)
+		result[idx] = append(result[idx], o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, stacktrace.Propagate(err, "Error in rows query result")
 	}
 
 	return result, nil
@@ -0,0 +1,62 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/golang/geo/s2"
+	dssmodels "github.com/interuss/dss/pkg/models"
+	scdmodels "github.com/interuss/dss/pkg/scd/models"
+)
+
+// benchRepo opens a *repo against the CockroachDB instance pointed to by the
+// CRDB_BENCH_URI environment variable, skipping the benchmark if it isn't
+// set. This mirrors the out-of-process CockroachDB dependency the rest of
+// this package's write paths exercise.
+func benchRepo(b *testing.B) *repo {
+	uri := os.Getenv("CRDB_BENCH_URI")
+	if uri == "" {
+		b.Skip("CRDB_BENCH_URI not set; skipping CockroachDB-backed benchmark")
+	}
+	db, err := sql.Open("postgres", uri)
+	if err != nil {
+		b.Fatalf("Error opening CockroachDB connection: %s", err)
+	}
+	return &repo{db: db, q: db}
+}
+
+// benchCells returns n distinct s2.CellIDs at leaf level, enough to exercise
+// pushOperation's cell-membership write path at varying sizes.
+func benchCells(n int) s2.CellUnion {
+	cells := make(s2.CellUnion, n)
+	leaf := s2.CellIDFromFace(0).ChildBeginAtLevel(s2.MaxLevel)
+	for i := range cells {
+		cells[i] = leaf + s2.CellID(i)<<2
+	}
+	return cells
+}
+
+func benchmarkPushOperationCells(b *testing.B, numCells int) {
+	ctx := context.Background()
+	store := benchRepo(b)
+
+	operation := &scdmodels.Operation{
+		ID:    dssmodels.ID("bench-operation"),
+		Owner: dssmodels.Owner("bench-owner"),
+		Cells: benchCells(numCells),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := store.pushOperation(ctx, store.q, operation); err != nil {
+			b.Fatalf("pushOperation failed for %d cells: %s", numCells, err)
+		}
+	}
+}
+
+func BenchmarkPushOperation1Cell(b *testing.B)     { benchmarkPushOperationCells(b, 1) }
+func BenchmarkPushOperation10Cells(b *testing.B)   { benchmarkPushOperationCells(b, 10) }
+func BenchmarkPushOperation100Cells(b *testing.B)  { benchmarkPushOperationCells(b, 100) }
+func BenchmarkPushOperation1000Cells(b *testing.B) { benchmarkPushOperationCells(b, 1000) }
@@ -0,0 +1,146 @@
+package cockroach
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	dssmodels "github.com/interuss/dss/pkg/models"
+	scdmodels "github.com/interuss/dss/pkg/scd/models"
+	"github.com/lib/pq"
+	"github.com/palantir/stacktrace"
+)
+
+func TestIsRetryableTxError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "serialization failure",
+			err:  &pq.Error{Code: "40001"},
+			want: true,
+		},
+		{
+			name: "retry write too old",
+			err:  &pq.Error{Code: "CR000"},
+			want: true,
+		},
+		{
+			name: "wrapped with stacktrace",
+			err:  stacktrace.Propagate(&pq.Error{Code: "40001"}, "while upserting"),
+			want: true,
+		},
+		{
+			name: "non-retryable postgres error",
+			err:  &pq.Error{Code: "23505"}, // unique_violation
+			want: false,
+		},
+		{
+			name: "not a postgres error at all",
+			err:  errors.New("connection reset"),
+			want: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isRetryableTxError(c.err); got != c.want {
+				t.Errorf("isRetryableTxError(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+func TestOperationsPageTokenRoundTrip(t *testing.T) {
+	want := &OperationsPageToken{
+		UpdatedAt: time.Date(2026, 7, 29, 12, 0, 0, 0, time.UTC),
+		ID:        dssmodels.ID("00000000-0000-0000-0000-000000000001"),
+	}
+
+	got, err := DecodeOperationsPageToken(want.Encode())
+	if err != nil {
+		t.Fatalf("DecodeOperationsPageToken: %s", err)
+	}
+	if !got.UpdatedAt.Equal(want.UpdatedAt) || got.ID != want.ID {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeOperationsPageTokenEmpty(t *testing.T) {
+	got, err := DecodeOperationsPageToken("")
+	if err != nil {
+		t.Fatalf("DecodeOperationsPageToken(\"\"): %s", err)
+	}
+	if got != nil {
+		t.Errorf("DecodeOperationsPageToken(\"\") = %+v, want nil", got)
+	}
+}
+
+func TestDecodeOperationsPageTokenInvalid(t *testing.T) {
+	if _, err := DecodeOperationsPageToken("not valid base64url!!"); err == nil {
+		t.Error("DecodeOperationsPageToken(invalid) = nil error, want error")
+	}
+}
+
+// TestMissingIntersectingOVNsArgsTimeBoundOrder pins the $4/$5 bind order
+// against a real regression: an earlier version of this series passed
+// (EndTime, StartTime) instead of (StartTime, EndTime), which silently
+// turned missingIntersectingOVNs's overlap test into a containment test and
+// let genuinely-overlapping Operations through the OVN check undetected.
+func TestMissingIntersectingOVNsArgsTimeBoundOrder(t *testing.T) {
+	start := time.Date(2026, 7, 29, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 7, 29, 11, 0, 0, 0, time.UTC)
+	operation := &scdmodels.Operation{
+		ID:            dssmodels.ID("operation-under-test"),
+		AltitudeLower: float32ptr(100),
+		AltitudeUpper: float32ptr(200),
+		StartTime:     &start,
+		EndTime:       &end,
+	}
+
+	args := missingIntersectingOVNsArgs(operation, nil)
+
+	const (
+		altitudeLowerArgIdx = 1
+		altitudeUpperArgIdx = 2
+		startsAtArgIdx      = 3
+		endsAtArgIdx        = 4
+	)
+	if len(args) <= endsAtArgIdx {
+		t.Fatalf("missingIntersectingOVNsArgs returned %d args, want at least %d", len(args), endsAtArgIdx+1)
+	}
+	if args[altitudeLowerArgIdx] != operation.AltitudeLower || args[altitudeUpperArgIdx] != operation.AltitudeUpper {
+		t.Errorf("altitude args = (%v, %v), want (%v, %v)", args[altitudeLowerArgIdx], args[altitudeUpperArgIdx], operation.AltitudeLower, operation.AltitudeUpper)
+	}
+	if args[startsAtArgIdx] != operation.StartTime || args[endsAtArgIdx] != operation.EndTime {
+		t.Errorf("time-bound args = (%v, %v), want ($4=StartTime, $5=EndTime) = (%v, %v)",
+			args[startsAtArgIdx], args[endsAtArgIdx], operation.StartTime, operation.EndTime)
+	}
+}
+
+func float32ptr(f float32) *float32 { return &f }
+
+// TestSearchOperationsMultiQueryCoalescesNullBounds pins a real regression:
+// an earlier version of searchOperationsMultiQueryFormat compared
+// scd_operations' own (nullable) altitude/time bounds directly, so an
+// Operation with an unbounded altitude or time silently disappeared from
+// multi-search results instead of matching every query. Guard the
+// COALESCE(..., true) wrapping on all four predicates by string inspection,
+// since exercising this against a live CockroachDB requires a seeded schema
+// this package doesn't carry.
+func TestSearchOperationsMultiQueryCoalescesNullBounds(t *testing.T) {
+	predicates := []string{
+		"COALESCE(scd_operations.altitude_upper >= queries.altitude_lower, true)",
+		"COALESCE(scd_operations.altitude_lower <= queries.altitude_upper, true)",
+		"COALESCE(scd_operations.ends_at >= queries.starts_at, true)",
+		"COALESCE(scd_operations.starts_at <= queries.ends_at, true)",
+	}
+	for _, p := range predicates {
+		if !strings.Contains(searchOperationsMultiQueryFormat, p) {
+			t.Errorf("searchOperationsMultiQueryFormat missing expected predicate: %s", p)
+		}
+	}
+}